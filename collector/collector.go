@@ -1,17 +1,33 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// WeatherProvider is a source of current conditions for a single lat/lng.
+// Implementations wrap a specific upstream weather API; Collector drives
+// them behind shared rate limiting and caching.
+type WeatherProvider interface {
+	// Name identifies the provider, e.g. for the "provider" metric label.
+	Name() string
+	// Version identifies the specific upstream API version in use, e.g. for
+	// the "version" label on self-metrics. Providers without a meaningful
+	// version (e.g. Open-Meteo only has one) can return any fixed string.
+	Version() string
+	Fetch(ctx context.Context, lat, lng float64) (*Conditions, error)
+}
+
 type openweathermapTemperature struct {
 	Temperature         float64 `json:"temp"`
 	Pressure            float64 `json:"pressure"`
@@ -68,6 +84,7 @@ type Openweathermap25ConditionsResponse struct {
 func (c *Openweathermap25ConditionsResponse) toConditions() *Conditions {
 	return &Conditions{
 		Temperature:       c.Main.Temperature,
+		Pressure:          c.Main.Pressure,
 		Humidity:          float64(c.Main.HumidityPercent),
 		WindSpeed:         c.Wind.Speed,
 		WindDirection:     c.Wind.Direction,
@@ -97,10 +114,21 @@ type Openweathermap30Conditions struct {
 func (c *Openweathermap30Conditions) toConditions() *Conditions {
 	return &Conditions{
 		Temperature:       c.Temperature,
+		FeelsLike:         c.FeelsLike,
+		Pressure:          float64(c.Pressure),
 		Humidity:          c.Humidity,
+		DewPoint:          c.DewPoint,
+		UVIndex:           c.UVI,
+		Visibility:        float64(c.Visibility),
 		WindSpeed:         c.WindSpeed,
 		WindDirection:     c.WindDirection,
+		WindGustSpeed:     c.WindGusts,
 		CloudCoverPercent: c.CloudCover,
+		Sunrise:           c.Sunrise,
+		Sunset:            c.Sunset,
+		Moonrise:          c.Moonrise,
+		Moonset:           c.Moonset,
+		MoonPhase:         c.MoonPhase,
 	}
 }
 
@@ -139,37 +167,87 @@ func windDirection(deg float64) string {
 	return "unknown"
 }
 
-var globalLimiter *rate.Limiter
+// SharedLimiter enforces --daily-openweather-call-limit across every
+// Collector/GroupCollector in the process, and additionally tracks how much
+// of today's (UTC) budget remains, e.g. for the
+// openweather_daily_quota_remaining self-metric.
+type SharedLimiter struct {
+	limiter    *rate.Limiter
+	dailyLimit int
+	cache      *DiskCache
 
-const (
-	OPENWEATHER_API_2_5 = iota
-	OPENWEATHER_API_3_0 = iota
-)
+	mu   sync.Mutex
+	day  time.Time
+	used int
+}
 
-type Collector struct {
-	apiVersion     int
-	lat, lng       float64
-	apiKey         string
-	limiter        *rate.Limiter
-	lastConditions *Conditions
+// NewSharedLimiter builds a SharedLimiter enforcing dailyLimit calls/day.
+// Pass the same *SharedLimiter to every Collector/GroupCollector in the
+// process so that --daily-openweather-call-limit budgets the whole process
+// rather than being silently multiplied by the number of
+// locations/providers configured.
+//
+// cache may be nil (equivalent to a DiskCache with persistence disabled);
+// when non-nil, NewSharedLimiter seeds today's used count from it and
+// persists every increment, so a restart (e.g. a Kubernetes rollout)
+// doesn't get to spend a fresh daily budget just by restarting: the
+// in-memory rate.Limiter's token bucket always grants an immediate call on
+// startup, but l.used (once restored) still reflects what was already
+// spent today.
+func NewSharedLimiter(dailyLimit int, cache *DiskCache) *SharedLimiter {
+	interval := time.Second * time.Duration(86400/dailyLimit)
+	log.Printf("allowing 1 call per %s, shared across all locations/providers", interval)
+	l := &SharedLimiter{limiter: rate.NewLimiter(rate.Every(interval), 1), dailyLimit: dailyLimit, cache: cache}
+	if cache != nil {
+		l.day, l.used = cache.LimiterState()
+	}
+	return l
 }
 
-func (c Collector) String() string {
-	return fmt.Sprintf("%f,%f", c.lat, c.lng)
+// resetIfNewDay rolls l.used over at UTC midnight. Must be called with l.mu
+// held.
+func (l *SharedLimiter) resetIfNewDay() {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if today.After(l.day) {
+		l.day = today
+		l.used = 0
+	}
 }
 
-func NewCollector(key string, dailyLimit int, lat, lng float64) *Collector {
-	interval := time.Second * time.Duration(86400/dailyLimit)
-	log.Printf("allowing 1 call per %s", interval)
-	return &Collector{
-		apiVersion: OPENWEATHER_API_2_5,
-		apiKey:     key,
-		limiter:    rate.NewLimiter(rate.Every(interval), 1),
-		lat:        lat,
-		lng:        lng,
+// Allow reports whether a call is permitted right now, counting it against
+// today's budget if so.
+func (l *SharedLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetIfNewDay()
+	if l.used >= l.dailyLimit {
+		return false
+	}
+	if !l.limiter.Allow() {
+		return false
 	}
+	l.used++
+	if l.cache != nil {
+		if err := l.cache.PutLimiterState(l.day, l.used); err != nil {
+			log.Printf("Error persisting daily call budget: %v", err)
+		}
+	}
+	return true
+}
+
+// Remaining returns how many calls are left in today's (UTC) budget.
+func (l *SharedLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetIfNewDay()
+	return l.dailyLimit - l.used
 }
 
+const (
+	OPENWEATHER_API_2_5 = iota
+	OPENWEATHER_API_3_0 = iota
+)
+
 func openWeatherEndpoint() string {
 	if e := os.Getenv("OPEN_WEATHER_ENDPOINT"); e != "" {
 		return e
@@ -177,17 +255,74 @@ func openWeatherEndpoint() string {
 	return "https://api.openweathermap.org"
 }
 
-func (c *Collector) get25Conditions() (*Conditions, error) {
+// redactAPIKey returns rawURL with any appid/APPID query parameter value
+// replaced, so request URLs can be logged without leaking the API key.
+func redactAPIKey(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := parsed.Query()
+	for _, param := range []string{"appid", "APPID"} {
+		if q.Get(param) != "" {
+			q.Set(param, "REDACTED")
+		}
+	}
+	parsed.RawQuery = q.Encode()
+	return parsed.String()
+}
+
+// openweatherProvider is the original WeatherProvider, backed by the
+// OpenWeather 2.5 or 3.0 (One Call) current-conditions API.
+type openweatherProvider struct {
+	apiKey  string
+	version int
+}
+
+func (p *openweatherProvider) Name() string { return "openweather" }
+
+func (p *openweatherProvider) Version() string {
+	switch p.version {
+	case OPENWEATHER_API_2_5:
+		return "2.5"
+	case OPENWEATHER_API_3_0:
+		return "3.0"
+	default:
+		return ""
+	}
+}
+
+func (p *openweatherProvider) Fetch(ctx context.Context, lat, lng float64) (*Conditions, error) {
+	switch p.version {
+	case OPENWEATHER_API_2_5:
+		return p.get25Conditions(ctx, lat, lng)
+	case OPENWEATHER_API_3_0:
+		return p.get30Conditions(ctx, lat, lng)
+	default:
+		return nil, fmt.Errorf("Unsupported openweather API version %d", p.version)
+	}
+}
+
+func (p *openweatherProvider) get25Conditions(ctx context.Context, lat, lng float64) (*Conditions, error) {
 	u := fmt.Sprintf(
 		"%s/data/2.5/weather?lat=%f&lon=%f&APPID=%s&units=metric",
-		openWeatherEndpoint(), c.lat, c.lng, url.QueryEscape(c.apiKey))
+		openWeatherEndpoint(), lat, lng, url.QueryEscape(p.apiKey))
 	log.Printf("calling 2.5 API at %s", u)
-	resp, err := http.Get(u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Error calling openweather: %v", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("openweather 2.5 API returned %s", resp.Status)
+		log.Printf("Error calling openweather: %v", err)
+		return nil, err
+	}
 	dec := json.NewDecoder(resp.Body)
 	wr := &Openweathermap25ConditionsResponse{}
 	if err = dec.Decode(wr); err != nil {
@@ -197,17 +332,26 @@ func (c *Collector) get25Conditions() (*Conditions, error) {
 	return wr.toConditions(), nil
 }
 
-func (c *Collector) get30Conditions() (*Conditions, error) {
+func (p *openweatherProvider) get30Conditions(ctx context.Context, lat, lng float64) (*Conditions, error) {
 	u := fmt.Sprintf(
 		"%s/data/3.0/onecall?lat=%f&lon=%f&appid=%s&exclude=minutely,daily,hourly,alerts&units=metric",
-		openWeatherEndpoint(), c.lat, c.lng, url.QueryEscape(c.apiKey))
+		openWeatherEndpoint(), lat, lng, url.QueryEscape(p.apiKey))
 	log.Printf("Calling 3.0 API at %s", u)
-	resp, err := http.Get(u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Error calling openweather: %v", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("openweather 3.0 API returned %s", resp.Status)
+		log.Printf("Error calling openweather: %v", err)
+		return nil, err
+	}
 	dec := json.NewDecoder(resp.Body)
 	wr := &Openweathermap30ConditionsResponse{}
 	if err = dec.Decode(wr); err != nil {
@@ -217,36 +361,681 @@ func (c *Collector) get30Conditions() (*Conditions, error) {
 	return wr.Current.toConditions(), nil
 }
 
-func (c *Collector) Conditions() (*Conditions, error) {
-	switch c.apiVersion {
-	case OPENWEATHER_API_2_5:
-		return c.get25Conditions()
-	case OPENWEATHER_API_3_0:
-		return c.get30Conditions()
-	default:
-		return nil, fmt.Errorf("Unsupported openweather API version %d", c.apiVersion)
+type openMeteoCurrentConditions struct {
+	Temperature   float64 `json:"temperature_2m"`
+	FeelsLike     float64 `json:"apparent_temperature"`
+	Humidity      float64 `json:"relative_humidity_2m"`
+	Pressure      float64 `json:"surface_pressure"`
+	CloudCover    float64 `json:"cloud_cover"`
+	WindSpeed     float64 `json:"wind_speed_10m"`
+	WindDirection float64 `json:"wind_direction_10m"`
+	WindGusts     float64 `json:"wind_gusts_10m"`
+}
+
+type openMeteoConditionsResponse struct {
+	Latitude  float64                    `json:"latitude"`
+	Longitude float64                    `json:"longitude"`
+	Current   openMeteoCurrentConditions `json:"current"`
+}
+
+func (r *openMeteoConditionsResponse) toConditions() *Conditions {
+	return &Conditions{
+		Temperature:       r.Current.Temperature,
+		FeelsLike:         r.Current.FeelsLike,
+		Pressure:          r.Current.Pressure,
+		Humidity:          r.Current.Humidity,
+		WindSpeed:         r.Current.WindSpeed,
+		WindDirection:     r.Current.WindDirection,
+		WindGustSpeed:     r.Current.WindGusts,
+		CloudCoverPercent: r.Current.CloudCover,
+	}
+}
+
+// openMeteoProvider is a WeatherProvider backed by Open-Meteo, which requires
+// no API key.
+type openMeteoProvider struct{}
+
+func (p *openMeteoProvider) Name() string { return "open-meteo" }
+
+func (p *openMeteoProvider) Version() string { return "v1" }
+
+func openMeteoEndpoint() string {
+	if e := os.Getenv("OPEN_METEO_ENDPOINT"); e != "" {
+		return e
+	}
+	return "https://api.open-meteo.com"
+}
+
+func (p *openMeteoProvider) Fetch(ctx context.Context, lat, lng float64) (*Conditions, error) {
+	u := fmt.Sprintf(
+		"%s/v1/forecast?latitude=%f&longitude=%f&current=%s",
+		openMeteoEndpoint(), lat, lng,
+		"temperature_2m,apparent_temperature,relative_humidity_2m,surface_pressure,"+
+			"cloud_cover,wind_speed_10m,wind_direction_10m,wind_gusts_10m")
+	log.Printf("Calling open-meteo API at %s", u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Error calling open-meteo: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("open-meteo API returned %s", resp.Status)
+		log.Printf("Error calling open-meteo: %v", err)
+		return nil, err
+	}
+	dec := json.NewDecoder(resp.Body)
+	wr := &openMeteoConditionsResponse{}
+	if err = dec.Decode(wr); err != nil {
+		log.Printf("Error decoding open-meteo response: %v", err)
+		return nil, err
+	}
+	return wr.toConditions(), nil
+}
+
+// ProviderFactory builds a WeatherProvider, given the API key configured for
+// the process (providers that don't need one, like Open-Meteo, ignore it).
+type ProviderFactory func(apiKey string) WeatherProvider
+
+var providerRegistry = map[string]ProviderFactory{
+	"openweather": func(apiKey string) WeatherProvider {
+		return &openweatherProvider{apiKey: apiKey, version: OPENWEATHER_API_2_5}
+	},
+	"open-meteo": func(apiKey string) WeatherProvider {
+		return &openMeteoProvider{}
+	},
+}
+
+// NewProvider looks up a registered WeatherProvider by name, e.g. the
+// "--provider" flag value.
+func NewProvider(name, apiKey string) (WeatherProvider, error) {
+	factory, ok := providerRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown weather provider %q", name)
 	}
+	return factory(apiKey), nil
+}
+
+// CacheEntry is one location's worth of DiskCache content.
+type CacheEntry struct {
+	Conditions *Conditions `json:"conditions"`
+	Timestamp  time.Time   `json:"timestamp"`
+}
+
+// diskCacheFile is the on-disk JSON schema for DiskCache: per-location
+// conditions, plus the SharedLimiter's day/used counters so a restart
+// doesn't silently reset how much of today's --daily-openweather-call-limit
+// budget has already been spent.
+type diskCacheFile struct {
+	Entries     map[string]CacheEntry `json:"entries"`
+	LimiterDay  time.Time             `json:"limiter_day,omitempty"`
+	LimiterUsed int                   `json:"limiter_used,omitempty"`
+}
+
+// DiskCache persists conditions per location, and the shared daily call
+// budget, to a JSON file, so a restart (e.g. a Kubernetes rollout) doesn't
+// have to burn an API call just to have something to export until the next
+// scheduled fetch, and doesn't get to spend a fresh daily budget just by
+// restarting. A DiskCache with an empty path keeps its entries in memory
+// only: Put/PutLimiterState update them but never write a file.
+type DiskCache struct {
+	path string
+
+	mu          sync.Mutex
+	entries     map[string]CacheEntry
+	limiterDay  time.Time
+	limiterUsed int
+}
+
+// LoadDiskCache reads an existing cache file, if any. A missing file is not
+// an error: it just means a cold start. Pass an empty path to disable
+// persistence entirely.
+func LoadDiskCache(path string) (*DiskCache, error) {
+	dc := &DiskCache{path: path, entries: map[string]CacheEntry{}}
+	if path == "" {
+		return dc, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return dc, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f diskCacheFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if f.Entries != nil {
+		dc.entries = f.Entries
+	}
+	dc.limiterDay, dc.limiterUsed = f.LimiterDay, f.LimiterUsed
+	return dc, nil
+}
+
+// Get returns the cached entry for location, if present.
+func (dc *DiskCache) Get(location string) (CacheEntry, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	e, ok := dc.entries[location]
+	return e, ok
+}
+
+// All returns a copy of every cached entry, keyed by location.
+func (dc *DiskCache) All() map[string]CacheEntry {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	out := make(map[string]CacheEntry, len(dc.entries))
+	for k, v := range dc.entries {
+		out[k] = v
+	}
+	return out
+}
+
+// Put records the conditions fetched for location at timestamp and, unless
+// persistence is disabled, rewrites the cache file.
+func (dc *DiskCache) Put(location string, conditions *Conditions, timestamp time.Time) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.entries[location] = CacheEntry{Conditions: conditions, Timestamp: timestamp}
+	return dc.write()
+}
+
+// LimiterState returns the persisted SharedLimiter day/used counters, if
+// any (the zero Time and 0 on a cold start or with persistence disabled).
+func (dc *DiskCache) LimiterState() (time.Time, int) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.limiterDay, dc.limiterUsed
+}
+
+// PutLimiterState persists the SharedLimiter's day/used counters, unless
+// persistence is disabled, so a restart doesn't silently reset how much of
+// today's budget has already been spent.
+func (dc *DiskCache) PutLimiterState(day time.Time, used int) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.limiterDay, dc.limiterUsed = day, used
+	return dc.write()
+}
+
+// write rewrites the cache file with the current entries and limiter state.
+// Must be called with dc.mu held.
+func (dc *DiskCache) write() error {
+	if dc.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(diskCacheFile{
+		Entries:     dc.entries,
+		LimiterDay:  dc.limiterDay,
+		LimiterUsed: dc.limiterUsed,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dc.path, b, 0o600)
+}
+
+type Collector struct {
+	provider WeatherProvider
+	lat, lng float64
+	// apiKey is retained alongside provider because Forecast() always calls
+	// the OpenWeather One Call API directly, regardless of which
+	// WeatherProvider was selected for current conditions.
+	apiKey         string
+	limiter        *SharedLimiter
+	lastConditions *Conditions
+	lastForecast   map[time.Duration]*ForecastPoint
+	cache          *DiskCache
+	cacheKey       string
+}
+
+func (c Collector) String() string {
+	return fmt.Sprintf("%f,%f", c.lat, c.lng)
+}
+
+// ProviderName returns the name of the WeatherProvider this Collector fetches
+// current conditions from, e.g. for the "provider" metric label.
+func (c *Collector) ProviderName() string {
+	return c.provider.Name()
+}
+
+// NewCollector builds a Collector for a single location/provider. cache may
+// be nil (equivalent to a DiskCache with persistence disabled); cacheKey
+// identifies this location/provider within it, e.g. "lat,lng/provider".
+func NewCollector(provider WeatherProvider, apiKey string, limiter *SharedLimiter, lat, lng float64, cache *DiskCache, cacheKey string) *Collector {
+	c := &Collector{
+		provider: provider,
+		apiKey:   apiKey,
+		limiter:  limiter,
+		lat:      lat,
+		lng:      lng,
+		cache:    cache,
+		cacheKey: cacheKey,
+	}
+	if cache != nil {
+		if entry, ok := cache.Get(cacheKey); ok {
+			c.lastConditions = entry.Conditions
+		}
+	}
+	return c
+}
+
+type openweathermapHourlyForecast struct {
+	Timestamp                  int64                       `json:"dt"`
+	Temperature                float64                     `json:"temp"`
+	CloudCover                 float64                     `json:"clouds"`
+	WindSpeed                  float64                     `json:"wind_speed"`
+	ProbabilityOfPrecipitation float64                     `json:"pop"`
+	Rain                       openweathermapPrecipitation `json:"rain"`
+	Snow                       openweathermapPrecipitation `json:"snow"`
+}
+
+func (h openweathermapHourlyForecast) toForecastPoint() *ForecastPoint {
+	return &ForecastPoint{
+		Temperature:              h.Temperature,
+		PrecipitationProbability: h.ProbabilityOfPrecipitation,
+		PrecipitationMM:          h.Rain.OneHour + h.Snow.OneHour,
+		WindSpeed:                h.WindSpeed,
+		CloudCoverPercent:        h.CloudCover,
+	}
+}
+
+type openweathermapDailyTemperature struct {
+	Day   float64 `json:"day"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Night float64 `json:"night"`
+	Eve   float64 `json:"eve"`
+	Morn  float64 `json:"morn"`
+}
+
+type openweathermapDailyForecast struct {
+	Timestamp                  int64                          `json:"dt"`
+	Temperature                openweathermapDailyTemperature `json:"temp"`
+	CloudCover                 float64                        `json:"clouds"`
+	WindSpeed                  float64                        `json:"wind_speed"`
+	ProbabilityOfPrecipitation float64                        `json:"pop"`
+	Rain                       float64                        `json:"rain"`
+	Snow                       float64                        `json:"snow"`
+}
+
+func (d openweathermapDailyForecast) toForecastPoint() *ForecastPoint {
+	return &ForecastPoint{
+		Temperature:              d.Temperature.Day,
+		PrecipitationProbability: d.ProbabilityOfPrecipitation,
+		PrecipitationMM:          d.Rain + d.Snow,
+		WindSpeed:                d.WindSpeed,
+		CloudCoverPercent:        d.CloudCover,
+	}
+}
+
+type Openweathermap30ForecastResponse struct {
+	Lat    float64                        `json:"lat"`
+	Lon    float64                        `json:"lon"`
+	Hourly []openweathermapHourlyForecast `json:"hourly"`
+	Daily  []openweathermapDailyForecast  `json:"daily"`
+}
+
+// nearestForecastPoint returns the hourly or daily forecast entry whose
+// timestamp is closest to target.
+func (fr *Openweathermap30ForecastResponse) nearestForecastPoint(target time.Time) *ForecastPoint {
+	var best *ForecastPoint
+	var bestDiff time.Duration = -1
+	consider := func(ts int64, point *ForecastPoint) {
+		diff := target.Sub(time.Unix(ts, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			bestDiff = diff
+			best = point
+		}
+	}
+	for _, h := range fr.Hourly {
+		consider(h.Timestamp, h.toForecastPoint())
+	}
+	for _, d := range fr.Daily {
+		consider(d.Timestamp, d.toForecastPoint())
+	}
+	return best
+}
+
+// ForecastPoint holds the forecast fields exported per horizon.
+type ForecastPoint struct {
+	Temperature              float64
+	PrecipitationProbability float64
+	PrecipitationMM          float64
+	WindSpeed                float64
+	CloudCoverPercent        float64
+}
+
+func (c *Collector) getForecast() (*Openweathermap30ForecastResponse, error) {
+	u := fmt.Sprintf(
+		"%s/data/3.0/onecall?lat=%f&lon=%f&appid=%s&exclude=minutely,current,alerts&units=metric",
+		openWeatherEndpoint(), c.lat, c.lng, url.QueryEscape(c.apiKey))
+	log.Printf("Calling 3.0 API for forecast at %s", redactAPIKey(u))
+	resp, err := http.Get(u)
+	if err != nil {
+		log.Printf("Error calling openweather: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("openweather forecast API returned %s", resp.Status)
+		log.Printf("Error calling openweather: %v", err)
+		return nil, err
+	}
+	dec := json.NewDecoder(resp.Body)
+	fr := &Openweathermap30ForecastResponse{}
+	if err = dec.Decode(fr); err != nil {
+		log.Printf("Error decoding openweather forecast response: %v", err)
+		return nil, err
+	}
+	return fr, nil
+}
+
+// Forecast fetches the hourly/daily forecast and returns the forecast point
+// nearest to now+horizon, for each requested horizon.
+func (c *Collector) Forecast(horizons []time.Duration) (map[time.Duration]*ForecastPoint, error) {
+	fr, err := c.getForecast()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	points := make(map[time.Duration]*ForecastPoint, len(horizons))
+	for _, h := range horizons {
+		points[h] = fr.nearestForecastPoint(now.Add(h))
+	}
+	return points, nil
+}
+
+// CollectForecast is the rate-limited entry point for forecast data, mirroring
+// Collect() for current conditions: it reuses the last successful forecast
+// when the shared limiter disallows a fresh API call.
+func (c *Collector) CollectForecast(horizons []time.Duration) (map[time.Duration]*ForecastPoint, error) {
+	if c.limiter.Allow() {
+		log.Printf("under rate limit, allowing forecast API call")
+		current, err := c.Forecast(horizons)
+		if err == nil {
+			c.lastForecast = current
+		}
+		return current, err
+	} else if c.lastForecast == nil {
+		return nil, fmt.Errorf("Rate limited, but no previous forecast to reuse")
+	}
+	log.Printf("ratelimited, reusing last forecast result")
+	return c.lastForecast, nil
+}
+
+func (c *Collector) Conditions() (*Conditions, error) {
+	return c.provider.Fetch(context.Background(), c.lat, c.lng)
 }
 
 type Conditions struct {
 	Temperature       float64
+	FeelsLike         float64
+	Pressure          float64
 	Humidity          float64
+	DewPoint          float64
+	UVIndex           float64
+	Visibility        float64
 	WindSpeed         float64
 	WindDirection     float64
+	WindGustSpeed     float64
 	CloudCoverPercent float64
+	// Sunrise, Sunset, Moonrise and Moonset are Unix timestamps (seconds); zero
+	// when the underlying API response didn't provide them (e.g. the 2.5 API).
+	Sunrise   int64
+	Sunset    int64
+	Moonrise  int64
+	Moonset   int64
+	MoonPhase float64
 }
 
-func (c *Collector) Collect() (*Conditions, error) {
+// CollectResult reports the outcome of a single Collect pass, with enough
+// detail for callers to drive observability metrics without this package
+// needing to depend on Prometheus: whether a live upstream call was
+// attempted (vs reusing cached conditions because the shared limiter denied
+// one), which API version served it, how long it took, and any error.
+type CollectResult struct {
+	Conditions  *Conditions
+	Version     string
+	Called      bool
+	RateLimited bool
+	Duration    time.Duration
+	Err         error
+}
+
+// CollectWithResult behaves like Collect, but reports the outcome as a
+// CollectResult instead of a bare (*Conditions, error) pair.
+func (c *Collector) CollectWithResult() CollectResult {
+	version := c.provider.Version()
 	if c.limiter.Allow() {
 		log.Printf("under rate limit, allowing API call")
+		start := time.Now()
 		current, err := c.Conditions()
+		result := CollectResult{Conditions: current, Version: version, Called: true, Duration: time.Since(start), Err: err}
 		if err == nil {
 			c.lastConditions = current
+			if c.cache != nil {
+				if err := c.cache.Put(c.cacheKey, current, time.Now()); err != nil {
+					log.Printf("Error writing weather cache: %v", err)
+				}
+			}
 		}
-		return current, err
+		return result
 	} else if c.lastConditions == nil {
-		return nil, fmt.Errorf("Rate limited, but no previous conditions to reuse")
+		return CollectResult{Version: version, RateLimited: true, Err: fmt.Errorf("Rate limited, but no previous conditions to reuse")}
 	}
 	log.Printf("ratelimited, reusing last result")
-	return c.lastConditions, nil
+	return CollectResult{Conditions: c.lastConditions, Version: version, RateLimited: true}
+}
+
+func (c *Collector) Collect() (*Conditions, error) {
+	result := c.CollectWithResult()
+	return result.Conditions, result.Err
+}
+
+// Point is a bare lat/lng pair, used by GroupCollector where a full Collector
+// (with its own provider and cache) would be overkill.
+type Point struct {
+	Lat, Lng float64
+}
+
+// maxGroupMembers mirrors OpenWeather's 20-city limit on the group/find APIs.
+const maxGroupMembers = 20
+
+// openweathermapGroupResponse is returned by /data/2.5/find; each list entry
+// has the same shape as the single-city /data/2.5/weather response.
+type openweathermapGroupResponse struct {
+	Count int                                  `json:"cnt"`
+	List  []Openweathermap25ConditionsResponse `json:"list"`
+}
+
+// nearestTo returns the conditions for the list entry closest to p, or an
+// error if the API returned no matches at all (e.g. a remote/ocean point
+// with no nearby cities in OpenWeather's database).
+func (r *openweathermapGroupResponse) nearestTo(p Point) (*Conditions, error) {
+	var best *Openweathermap25ConditionsResponse
+	var bestDist float64 = -1
+	for i := range r.List {
+		item := &r.List[i]
+		dLat := item.Location.Lat - p.Lat
+		dLng := item.Location.Lng - p.Lng
+		dist := dLat*dLat + dLng*dLng
+		if bestDist < 0 || dist < bestDist {
+			bestDist = dist
+			best = item
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("openweather group API returned no matches near %v", p)
+	}
+	return best.toConditions(), nil
+}
+
+func centroid(points []Point) Point {
+	var sumLat, sumLng float64
+	for _, p := range points {
+		sumLat += p.Lat
+		sumLng += p.Lng
+	}
+	n := float64(len(points))
+	return Point{Lat: sumLat / n, Lng: sumLng / n}
+}
+
+func withinRadius(a, b Point, radiusDegrees float64) bool {
+	dLat := a.Lat - b.Lat
+	dLng := a.Lng - b.Lng
+	return dLat*dLat+dLng*dLng <= radiusDegrees*radiusDegrees
+}
+
+// clusterPoints greedily groups points so that every member of a cluster is
+// within radiusDegrees of the cluster's seed point, capped at maxSize (the
+// Group/find API's city limit).
+func clusterPoints(points []Point, radiusDegrees float64, maxSize int) [][]Point {
+	remaining := append([]Point(nil), points...)
+	var clusters [][]Point
+	for len(remaining) > 0 {
+		seed := remaining[0]
+		cluster := []Point{seed}
+		var rest []Point
+		for _, p := range remaining[1:] {
+			if len(cluster) < maxSize && withinRadius(seed, p, radiusDegrees) {
+				cluster = append(cluster, p)
+			} else {
+				rest = append(rest, p)
+			}
+		}
+		clusters = append(clusters, cluster)
+		remaining = rest
+	}
+	return clusters
+}
+
+// groupAPIVersion is the OpenWeather API version GroupCollector always
+// fetches from, for self-metrics.
+const groupAPIVersion = "2.5"
+
+// GroupCollector batches current-conditions lookups for nearby locations into
+// a single call to OpenWeather's /data/2.5/find ("nearby cities") API,
+// instead of one /data/2.5/weather call per location. This conserves quota
+// when --location points are clustered together (e.g. multiple sensors in the
+// same city), since the shared limiter passed in counts one API call per
+// cluster rather than one per location.
+type GroupCollector struct {
+	apiKey        string
+	radiusDegrees float64
+	limiter       *SharedLimiter
+	points        []Point
+
+	mu        sync.Mutex
+	ttl       time.Duration
+	lastFetch time.Time
+	cache     map[Point]*Conditions
+	results   map[Point]CollectResult
+}
+
+func NewGroupCollector(apiKey string, limiter *SharedLimiter, points []Point, radiusDegrees float64, ttl time.Duration) *GroupCollector {
+	return &GroupCollector{
+		apiKey:        apiKey,
+		limiter:       limiter,
+		points:        points,
+		radiusDegrees: radiusDegrees,
+		ttl:           ttl,
+		cache:         map[Point]*Conditions{},
+		results:       map[Point]CollectResult{},
+	}
+}
+
+func (g *GroupCollector) find(center Point, cnt int) (*openweathermapGroupResponse, error) {
+	if cnt > maxGroupMembers {
+		cnt = maxGroupMembers
+	}
+	u := fmt.Sprintf(
+		"%s/data/2.5/find?lat=%f&lon=%f&cnt=%d&appid=%s&units=metric",
+		openWeatherEndpoint(), center.Lat, center.Lng, cnt, url.QueryEscape(g.apiKey))
+	log.Printf("Calling 2.5 group/find API at %s", redactAPIKey(u))
+	resp, err := http.Get(u)
+	if err != nil {
+		log.Printf("Error calling openweather group API: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("openweather group API returned %s", resp.Status)
+		log.Printf("Error calling openweather group API: %v", err)
+		return nil, err
+	}
+	dec := json.NewDecoder(resp.Body)
+	gr := &openweathermapGroupResponse{}
+	if err = dec.Decode(gr); err != nil {
+		log.Printf("Error decoding openweather group response: %v", err)
+		return nil, err
+	}
+	return gr, nil
+}
+
+// refresh re-fetches every cluster that the shared limiter still allows a
+// call for this round, leaving clusters it doesn't allow on their last cached
+// result.
+func (g *GroupCollector) refresh() {
+	for _, cluster := range clusterPoints(g.points, g.radiusDegrees, maxGroupMembers) {
+		if !g.limiter.Allow() {
+			log.Printf("ratelimited, reusing last group result for cluster of %d location(s)", len(cluster))
+			for _, p := range cluster {
+				if cond, ok := g.cache[p]; ok {
+					g.results[p] = CollectResult{Conditions: cond, Version: groupAPIVersion, RateLimited: true}
+				} else {
+					g.results[p] = CollectResult{Version: groupAPIVersion, RateLimited: true, Err: fmt.Errorf("Rate limited, but no previous conditions to reuse for %v", p)}
+				}
+			}
+			continue
+		}
+		start := time.Now()
+		resp, err := g.find(centroid(cluster), len(cluster))
+		dur := time.Since(start)
+		if err != nil {
+			for _, p := range cluster {
+				g.results[p] = CollectResult{Version: groupAPIVersion, Called: true, Duration: dur, Err: err}
+			}
+			continue
+		}
+		for _, p := range cluster {
+			cond, err := resp.nearestTo(p)
+			if err != nil {
+				g.results[p] = CollectResult{Version: groupAPIVersion, Called: true, Duration: dur, Err: err}
+				continue
+			}
+			g.cache[p] = cond
+			g.results[p] = CollectResult{Conditions: cond, Version: groupAPIVersion, Called: true, Duration: dur}
+		}
+	}
+	g.lastFetch = time.Now()
+}
+
+// CollectWithResult behaves like Collect, but reports the outcome as a
+// CollectResult instead of a bare (*Conditions, error) pair, refreshing the
+// whole group (not just p) when the cache has gone stale.
+func (g *GroupCollector) CollectWithResult(p Point) CollectResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.lastFetch.IsZero() || time.Since(g.lastFetch) >= g.ttl {
+		g.refresh()
+	}
+	if result, ok := g.results[p]; ok {
+		return result
+	}
+	return CollectResult{Version: groupAPIVersion, Err: fmt.Errorf("Rate limited, but no previous conditions to reuse for %v", p)}
+}
+
+// Collect returns the current conditions nearest to p, refreshing the whole
+// group (not just p) when the cache has gone stale.
+func (g *GroupCollector) Collect(p Point) (*Conditions, error) {
+	result := g.CollectWithResult(p)
+	return result.Conditions, result.Err
 }
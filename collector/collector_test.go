@@ -8,6 +8,7 @@ import (
 	"os"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestWindDirection(t *testing.T) {
@@ -53,7 +54,7 @@ func TestCollect25Once(t *testing.T) {
 	calls := &atomic.Uint32{}
 	srv := setupServer(calls)
 	defer srv.Close()
-	c := NewCollector("key here", 1000, 123, 45)
+	c := NewCollector(&openweatherProvider{apiKey: "key here", version: OPENWEATHER_API_2_5}, "key here", NewSharedLimiter(1000, nil), 123, 45, nil, "")
 	cond, err := c.Collect()
 	if err != nil {
 		t.Errorf("Error collecting 2.5 weather: %v", err)
@@ -80,7 +81,7 @@ func TestCollect25Ratelimited(t *testing.T) {
 	calls := &atomic.Uint32{}
 	srv := setupServer(calls)
 	defer srv.Close()
-	c := NewCollector("key here", 10, 123, 45)
+	c := NewCollector(&openweatherProvider{apiKey: "key here", version: OPENWEATHER_API_2_5}, "key here", NewSharedLimiter(10, nil), 123, 45, nil, "")
 	for i := 0; i < 10; i++ {
 		_, err := c.Collect()
 		if err != nil {
@@ -92,3 +93,166 @@ func TestCollect25Ratelimited(t *testing.T) {
 		t.Errorf("handler was called %d times, want 1", got)
 	}
 }
+
+func TestClusterPoints(t *testing.T) {
+	near1 := Point{Lat: 0, Lng: 0}
+	near2 := Point{Lat: 0.1, Lng: 0.1}
+	far := Point{Lat: 10, Lng: 10}
+	clusters := clusterPoints([]Point{near1, near2, far}, 1, 20)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2: %+v", len(clusters), clusters)
+	}
+	if len(clusters[0]) != 2 {
+		t.Errorf("got %d points in first cluster, want 2: %+v", len(clusters[0]), clusters[0])
+	}
+	if len(clusters[1]) != 1 {
+		t.Errorf("got %d points in second cluster, want 1: %+v", len(clusters[1]), clusters[1])
+	}
+}
+
+func TestClusterPointsRespectsMaxSize(t *testing.T) {
+	points := make([]Point, 5)
+	for i := range points {
+		points[i] = Point{Lat: 0, Lng: float64(i) * 0.01}
+	}
+	clusters := clusterPoints(points, 1, 2)
+	for _, c := range clusters {
+		if len(c) > 2 {
+			t.Errorf("got cluster of size %d, want at most 2: %+v", len(c), c)
+		}
+	}
+}
+
+func TestNearestTo(t *testing.T) {
+	resp := &openweathermapGroupResponse{
+		List: []Openweathermap25ConditionsResponse{
+			{Location: openweathermapLocation{Lat: 0, Lng: 0}, Main: openweathermapTemperature{Temperature: 1}},
+			{Location: openweathermapLocation{Lat: 5, Lng: 5}, Main: openweathermapTemperature{Temperature: 2}},
+		},
+	}
+	cond, err := resp.nearestTo(Point{Lat: 0.1, Lng: 0.1})
+	if err != nil {
+		t.Fatalf("nearestTo: %v", err)
+	}
+	if cond.Temperature != 1 {
+		t.Errorf("got temperature %f, want 1", cond.Temperature)
+	}
+}
+
+func TestNearestToNoMatch(t *testing.T) {
+	resp := &openweathermapGroupResponse{}
+	if _, err := resp.nearestTo(Point{Lat: 0, Lng: 0}); err == nil {
+		t.Error("nearestTo on an empty list returned no error, want one")
+	}
+}
+
+func TestDiskCachePersistsLimiterState(t *testing.T) {
+	path := t.TempDir() + "/cache.json"
+	dc, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache: %v", err)
+	}
+	if day, used := dc.LimiterState(); !day.IsZero() || used != 0 {
+		t.Errorf("got day=%v used=%d on empty cache, want zero/0", day, used)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if err := dc.PutLimiterState(today, 7); err != nil {
+		t.Fatalf("PutLimiterState: %v", err)
+	}
+
+	reloaded, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache (reload): %v", err)
+	}
+	if day, used := reloaded.LimiterState(); !day.Equal(today) || used != 7 {
+		t.Errorf("got day=%v used=%d after reload, want %v/7", day, used, today)
+	}
+}
+
+func TestSharedLimiterDeniesAfterRestartWithBudgetSpent(t *testing.T) {
+	path := t.TempDir() + "/cache.json"
+	dc, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache: %v", err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if err := dc.PutLimiterState(today, 5); err != nil {
+		t.Fatalf("PutLimiterState: %v", err)
+	}
+
+	reloaded, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache (reload): %v", err)
+	}
+	// A fresh SharedLimiter, as built after a restart (e.g. a Kubernetes
+	// rollout), has a fresh rate.Limiter token bucket that would otherwise
+	// grant an immediate call regardless of how much of today's budget was
+	// already spent. Restoring used from the cache must still deny it.
+	limiter := NewSharedLimiter(5, reloaded)
+	if limiter.Allow() {
+		t.Error("Allow after restart with budget already spent: got true, want false")
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	path := t.TempDir() + "/cache.json"
+	dc, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache: %v", err)
+	}
+	if _, ok := dc.Get("1,2/openweather"); ok {
+		t.Errorf("Get on empty cache returned ok=true, want false")
+	}
+	want := &Conditions{Temperature: 20.24, Humidity: 59}
+	if err := dc.Put("1,2/openweather", want, time.Unix(1000, 0)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reloaded, err := LoadDiskCache(path)
+	if err != nil {
+		t.Fatalf("LoadDiskCache (reload): %v", err)
+	}
+	entry, ok := reloaded.Get("1,2/openweather")
+	if !ok {
+		t.Fatalf("Get after reload returned ok=false, want true")
+	}
+	if *entry.Conditions != *want {
+		t.Errorf("got conditions %+v, want %+v", entry.Conditions, want)
+	}
+	if !entry.Timestamp.Equal(time.Unix(1000, 0)) {
+		t.Errorf("got timestamp %v, want %v", entry.Timestamp, time.Unix(1000, 0))
+	}
+}
+
+func TestNewCollectorSeedsFromCache(t *testing.T) {
+	calls := &atomic.Uint32{}
+	srv := setupServer(calls)
+	defer srv.Close()
+
+	dc, err := LoadDiskCache("")
+	if err != nil {
+		t.Fatalf("LoadDiskCache: %v", err)
+	}
+	want := &Conditions{Temperature: 99}
+	if err := dc.Put("123.000000,45.000000/openweather", want, time.Unix(1, 0)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Drain the limiter's burst so Collect falls back to whatever
+	// NewCollector seeded from the cache instead of calling the server.
+	limiter := NewSharedLimiter(1000, nil)
+	limiter.Allow()
+
+	c := NewCollector(&openweatherProvider{apiKey: "key here", version: OPENWEATHER_API_2_5}, "key here", limiter, 123, 45, dc, "123.000000,45.000000/openweather")
+	cond, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if cond.Temperature != want.Temperature {
+		t.Errorf("got temperature %f, want %f", cond.Temperature, want.Temperature)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("handler was called %d times, want 0", got)
+	}
+}
@@ -28,8 +28,49 @@ var (
 		"API key for Openweather")
 	openweatherAPIKeyFile = flag.String("openweather-api-key-file", "",
 		"File containing API key for Openweather")
+	groupRadiusDegrees = flag.Float64("group-radius-degrees", 0,
+		"Batch openweather locations within this many degrees lat/lng of each other into a single"+
+			" /data/2.5/find API call instead of one call per location (0 disables batching)")
+	cacheFile = flag.String("cache-file", "",
+		"Persist conditions per location to this JSON file so a restart doesn't have to burn an"+
+			" API call before the next scheduled fetch (empty disables persistence)")
 )
 
+// Self-metrics giving operators a way to tell "the exporter is broken" apart
+// from "it's genuinely -0°C outside": live upstream call volume/latency,
+// how often the shared limiter denied a live call, and when each
+// location was last fetched successfully. Registered once at startup;
+// populated per location/provider by ttlCollector.reCollect().
+var (
+	apiCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "openweather",
+		Name:      "api_calls_total",
+		Help:      "Live upstream API calls made while collecting current conditions, by result",
+	}, []string{"location", "provider", "version", "result"})
+
+	apiCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "openweather",
+		Name:      "api_call_duration_seconds",
+		Help:      "Latency of live upstream API calls made while collecting current conditions",
+	}, []string{"location", "provider", "version"})
+
+	rateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "openweather",
+		Name:      "rate_limited_total",
+		Help:      "Collections for which the shared call limiter denied a live API call (cached conditions are reused when available)",
+	}, []string{"location", "provider"})
+
+	lastSuccessTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "openweather",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful conditions fetch",
+	}, []string{"location", "provider"})
+)
+
+func init() {
+	prometheus.MustRegister(apiCallsTotal, apiCallDuration, rateLimitedTotal, lastSuccessTimestamp)
+}
+
 type GeoPoint struct {
 	lat, lng float64
 }
@@ -67,6 +108,51 @@ func (ll *locationList) Set(value string) error {
 	return nil
 }
 
+type forecastHorizon struct {
+	label    string
+	duration time.Duration
+}
+
+type horizonList []forecastHorizon
+
+var forecastHorizons horizonList
+
+func (hl *horizonList) String() string {
+	s := make([]string, len(*hl))
+	for i, h := range *hl {
+		s[i] = h.label
+	}
+	return strings.Join(s, ",")
+}
+
+func (hl *horizonList) Set(value string) error {
+	for _, tok := range strings.Split(value, ",") {
+		tok = strings.TrimSpace(tok)
+		if tok == "" {
+			continue
+		}
+		d, err := time.ParseDuration(tok)
+		if err != nil {
+			return fmt.Errorf("Unparseable forecast horizon %q: %v", tok, err)
+		}
+		*hl = append(*hl, forecastHorizon{label: tok, duration: d})
+	}
+	return nil
+}
+
+type providerList []string
+
+var providers providerList
+
+func (pl *providerList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+func (pl *providerList) Set(value string) error {
+	*pl = append(*pl, strings.TrimSpace(value))
+	return nil
+}
+
 func readKeyOrFile(key, keyFile string) (string, error) {
 	if key != "" {
 		return key, nil
@@ -83,12 +169,39 @@ func readKeyOrFile(key, keyFile string) (string, error) {
 
 func init() {
 	flag.Var(&locations, "location", "lat,lng to collect")
+	flag.Var(&forecastHorizons, "forecast-horizons", "Comma-separated forecast horizons to export as gauges, e.g. 1h,3h,24h,48h (empty disables forecast export)")
+	flag.Var(&providers, "provider", "Weather provider to collect from (openweather, open-meteo); repeat to run providers side-by-side for cross-checking. Defaults to openweather")
+}
+
+// conditionsCollector is satisfied by *collector.Collector (one location, one
+// API call) and by groupMember (one location fanned out from a shared
+// collector.GroupCollector call covering several nearby locations).
+type conditionsCollector interface {
+	CollectWithResult() collector.CollectResult
+}
+
+// groupMember adapts a single Point of a collector.GroupCollector to the
+// conditionsCollector interface so ttlCollector doesn't need to know whether
+// a location is collected individually or as part of a group.
+type groupMember struct {
+	group *collector.GroupCollector
+	point collector.Point
 }
 
-var conditionMutex sync.Mutex
+func (m *groupMember) CollectWithResult() collector.CollectResult {
+	return m.group.CollectWithResult(m.point)
+}
 
+// ttlCollector caches one location/provider's conditions for collectionTTL
+// and records the openweather_* self-metrics for every reCollect() that
+// falls through to the underlying conditionsCollector. Its own mutex (rather
+// than a package-wide one) means a slow or rate-limited location doesn't
+// block metric collection for any other location.
 type ttlCollector struct {
-	collector  *collector.Collector
+	mu         sync.Mutex
+	collector  conditionsCollector
+	location   string
+	provider   string
 	conditions *collector.Conditions
 	timestamp  time.Time
 }
@@ -98,112 +211,232 @@ var collectors = map[string]*ttlCollector{}
 const collectionTTL = 10 * time.Second
 
 func (tc *ttlCollector) reCollect() (*collector.Conditions, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 	now := time.Now()
 	if !tc.timestamp.IsZero() && now.Sub(tc.timestamp) < collectionTTL {
 		return tc.conditions, nil
-	} else {
-		cond, err := tc.collector.Collect()
-		if err == nil {
-			tc.conditions = cond
-			tc.timestamp = now
+	}
+	result := tc.collector.CollectWithResult()
+	if result.Called {
+		status := "success"
+		if result.Err != nil {
+			status = "error"
 		}
-		return cond, err
-	}
-}
-
-func export(tc *ttlCollector, location string) error {
-	prometheus.MustRegister(
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "temperature_celsius",
-				Help:        "Current local temperature, in °C",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.Temperature
-				}
-			}),
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "pressure_hpa",
-				Help:        "Current local atmospheric pressure (hectopascals)",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.Pressure
-				}
-			}),
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "humidity",
-				Help:        "Current local humidity",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.Humidity
-				}
-			}),
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "wind_speed_meters_per_sec",
-				Help:        "Current local wind speed, in meters/sec",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.WindSpeed
-				}
-			}),
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "wind_direction_degrees",
-				Help:        "Current local wind direction, in degrees from 0° (North)",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.WindDirection
-				}
-			}),
-		prometheus.NewGaugeFunc(
-			prometheus.GaugeOpts{
-				Namespace:   "weather",
-				Name:        "cloud_cover_percent",
-				Help:        "Current local cloud cover, in percent",
-				ConstLabels: prometheus.Labels{"location": location},
-			}, func() float64 {
-				conditionMutex.Lock()
-				defer conditionMutex.Unlock()
-				if cond, err := tc.reCollect(); err != nil {
-					return math.NaN()
-				} else {
-					return cond.CloudCoverPercent
-				}
-			}),
-	)
+		apiCallsTotal.WithLabelValues(tc.location, tc.provider, result.Version, status).Inc()
+		apiCallDuration.WithLabelValues(tc.location, tc.provider, result.Version).Observe(result.Duration.Seconds())
+	}
+	if result.RateLimited {
+		rateLimitedTotal.WithLabelValues(tc.location, tc.provider).Inc()
+	}
+	if result.Err == nil {
+		if result.Called {
+			lastSuccessTimestamp.WithLabelValues(tc.location, tc.provider).Set(float64(now.Unix()))
+		}
+		tc.conditions = result.Conditions
+		tc.timestamp = now
+	}
+	return result.Conditions, result.Err
+}
+
+var forecastMutex sync.Mutex
+
+type ttlForecastCollector struct {
+	collector *collector.Collector
+	points    map[time.Duration]*collector.ForecastPoint
+	timestamp time.Time
+}
+
+func (tc *ttlForecastCollector) reCollect() (map[time.Duration]*collector.ForecastPoint, error) {
+	now := time.Now()
+	if !tc.timestamp.IsZero() && now.Sub(tc.timestamp) < collectionTTL {
+		return tc.points, nil
+	}
+	durations := make([]time.Duration, len(forecastHorizons))
+	for i, h := range forecastHorizons {
+		durations[i] = h.duration
+	}
+	points, err := tc.collector.CollectForecast(durations)
+	if err == nil {
+		tc.points = points
+		tc.timestamp = now
+	}
+	return points, err
+}
+
+func forecastGaugeFunc(tc *ttlForecastCollector, horizon forecastHorizon, extract func(*collector.ForecastPoint) float64) func() float64 {
+	return func() float64 {
+		forecastMutex.Lock()
+		defer forecastMutex.Unlock()
+		points, err := tc.reCollect()
+		if err != nil {
+			return math.NaN()
+		}
+		point, ok := points[horizon.duration]
+		if !ok {
+			return math.NaN()
+		}
+		return extract(point)
+	}
+}
+
+func exportForecast(tc *ttlForecastCollector, location, provider string) error {
+	for _, horizon := range forecastHorizons {
+		labels := prometheus.Labels{"location": location, "provider": provider, "horizon": horizon.label}
+		prometheus.MustRegister(
+			prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "weather",
+					Name:        "forecast_temperature_celsius",
+					Help:        "Forecast temperature at the given horizon, in °C",
+					ConstLabels: labels,
+				}, forecastGaugeFunc(tc, horizon, func(p *collector.ForecastPoint) float64 { return p.Temperature })),
+			prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "weather",
+					Name:        "forecast_precipitation_probability",
+					Help:        "Forecast probability of precipitation at the given horizon, from 0 to 1",
+					ConstLabels: labels,
+				}, forecastGaugeFunc(tc, horizon, func(p *collector.ForecastPoint) float64 { return p.PrecipitationProbability })),
+			prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "weather",
+					Name:        "forecast_precipitation_mm",
+					Help:        "Forecast precipitation (rain+snow) at the given horizon, in mm",
+					ConstLabels: labels,
+				}, forecastGaugeFunc(tc, horizon, func(p *collector.ForecastPoint) float64 { return p.PrecipitationMM })),
+			prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "weather",
+					Name:        "forecast_wind_speed_meters_per_sec",
+					Help:        "Forecast wind speed at the given horizon, in meters/sec",
+					ConstLabels: labels,
+				}, forecastGaugeFunc(tc, horizon, func(p *collector.ForecastPoint) float64 { return p.WindSpeed })),
+			prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "weather",
+					Name:        "forecast_cloud_cover_percent",
+					Help:        "Forecast cloud cover at the given horizon, in percent",
+					ConstLabels: labels,
+				}, forecastGaugeFunc(tc, horizon, func(p *collector.ForecastPoint) float64 { return p.CloudCoverPercent })),
+		)
+	}
+	return nil
+}
+
+// locationMetrics is a prometheus.Collector that gathers every current-
+// conditions gauge for one location/provider pair from a single
+// tc.reCollect() call, so a failed fetch (or a rate-limited one with no
+// earlier conditions to reuse) marks every reading NaN (and `up` 0)
+// together, instead of each gauge's own GaugeFunc calling
+// reCollect() independently and risking a torn snapshot across metrics.
+type locationMetrics struct {
+	tc *ttlCollector
+
+	temperature   *prometheus.Desc
+	pressure      *prometheus.Desc
+	feelsLike     *prometheus.Desc
+	dewPoint      *prometheus.Desc
+	uvIndex       *prometheus.Desc
+	visibility    *prometheus.Desc
+	windGust      *prometheus.Desc
+	sunrise       *prometheus.Desc
+	sunset        *prometheus.Desc
+	moonrise      *prometheus.Desc
+	moonset       *prometheus.Desc
+	moonPhase     *prometheus.Desc
+	humidity      *prometheus.Desc
+	windSpeed     *prometheus.Desc
+	windDirection *prometheus.Desc
+	cloudCover    *prometheus.Desc
+	up            *prometheus.Desc
+}
+
+func newLocationMetrics(tc *ttlCollector, location, provider string) *locationMetrics {
+	labels := prometheus.Labels{"location": location, "provider": provider}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("weather", "", name), help, nil, labels)
+	}
+	return &locationMetrics{
+		tc:            tc,
+		temperature:   desc("temperature_celsius", "Current local temperature, in °C"),
+		pressure:      desc("pressure_hpa", "Current local atmospheric pressure (hectopascals)"),
+		feelsLike:     desc("feels_like_celsius", "Current local \"feels like\" temperature, in °C"),
+		dewPoint:      desc("dew_point_celsius", "Current local dew point, in °C"),
+		uvIndex:       desc("uv_index", "Current local UV index"),
+		visibility:    desc("visibility_meters", "Current local visibility, in meters"),
+		windGust:      desc("wind_gust_meters_per_sec", "Current local wind gust speed, in meters/sec"),
+		sunrise:       desc("sunrise_seconds", "Local sunrise time, in seconds since the Unix epoch"),
+		sunset:        desc("sunset_seconds", "Local sunset time, in seconds since the Unix epoch"),
+		moonrise:      desc("moonrise_seconds", "Local moonrise time, in seconds since the Unix epoch"),
+		moonset:       desc("moonset_seconds", "Local moonset time, in seconds since the Unix epoch"),
+		moonPhase:     desc("moon_phase", "Current moon phase, from 0 (new moon) to 1 (next new moon)"),
+		humidity:      desc("humidity", "Current local humidity"),
+		windSpeed:     desc("wind_speed_meters_per_sec", "Current local wind speed, in meters/sec"),
+		windDirection: desc("wind_direction_degrees", "Current local wind direction, in degrees from 0° (North)"),
+		cloudCover:    desc("cloud_cover_percent", "Current local cloud cover, in percent"),
+		up:            prometheus.NewDesc(prometheus.BuildFQName("openweather", "location", "up"), "Whether the last conditions fetch for this location/provider succeeded", nil, labels),
+	}
+}
+
+func (m *locationMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.temperature
+	ch <- m.pressure
+	ch <- m.feelsLike
+	ch <- m.dewPoint
+	ch <- m.uvIndex
+	ch <- m.visibility
+	ch <- m.windGust
+	ch <- m.sunrise
+	ch <- m.sunset
+	ch <- m.moonrise
+	ch <- m.moonset
+	ch <- m.moonPhase
+	ch <- m.humidity
+	ch <- m.windSpeed
+	ch <- m.windDirection
+	ch <- m.cloudCover
+	ch <- m.up
+}
+
+func (m *locationMetrics) Collect(ch chan<- prometheus.Metric) {
+	cond, err := m.tc.reCollect()
+	up := 1.0
+	if err != nil {
+		up = 0
+		cond = nil
+	}
+	ch <- prometheus.MustNewConstMetric(m.up, prometheus.GaugeValue, up)
+
+	field := func(extract func(*collector.Conditions) float64) float64 {
+		if cond == nil {
+			return math.NaN()
+		}
+		return extract(cond)
+	}
+	emit := func(desc *prometheus.Desc, extract func(*collector.Conditions) float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, field(extract))
+	}
+	emit(m.temperature, func(c *collector.Conditions) float64 { return c.Temperature })
+	emit(m.pressure, func(c *collector.Conditions) float64 { return c.Pressure })
+	emit(m.feelsLike, func(c *collector.Conditions) float64 { return c.FeelsLike })
+	emit(m.dewPoint, func(c *collector.Conditions) float64 { return c.DewPoint })
+	emit(m.uvIndex, func(c *collector.Conditions) float64 { return c.UVIndex })
+	emit(m.visibility, func(c *collector.Conditions) float64 { return c.Visibility })
+	emit(m.windGust, func(c *collector.Conditions) float64 { return c.WindGustSpeed })
+	emit(m.sunrise, func(c *collector.Conditions) float64 { return float64(c.Sunrise) })
+	emit(m.sunset, func(c *collector.Conditions) float64 { return float64(c.Sunset) })
+	emit(m.moonrise, func(c *collector.Conditions) float64 { return float64(c.Moonrise) })
+	emit(m.moonset, func(c *collector.Conditions) float64 { return float64(c.Moonset) })
+	emit(m.moonPhase, func(c *collector.Conditions) float64 { return c.MoonPhase })
+	emit(m.humidity, func(c *collector.Conditions) float64 { return c.Humidity })
+	emit(m.windSpeed, func(c *collector.Conditions) float64 { return c.WindSpeed })
+	emit(m.windDirection, func(c *collector.Conditions) float64 { return c.WindDirection })
+	emit(m.cloudCover, func(c *collector.Conditions) float64 { return c.CloudCoverPercent })
+}
+
+func export(tc *ttlCollector, location, provider string) error {
+	prometheus.MustRegister(newLocationMetrics(tc, location, provider))
 	return nil
 }
 
@@ -213,22 +446,98 @@ func main() {
 		log.Printf("At least one --location is required")
 		os.Exit(2)
 	}
-	if *openweatherAPIKey == "" && *openweatherAPIKeyFile == "" {
-		log.Printf("One of --openweather-api-key or --openweather-api-key-file is required")
+	if len(providers) == 0 {
+		providers = providerList{"openweather"}
+	}
+
+	// The forecast subsystem always calls the OpenWeather API directly (see
+	// Collector.getForecast), regardless of which WeatherProvider was
+	// selected for current conditions. Location batching (see
+	// GroupCollector.find) also calls OpenWeather, though only for the
+	// openweather provider's locations. Either one requires a key, even for
+	// a --provider=open-meteo-only process.
+	needsOpenweatherKey := *groupRadiusDegrees > 0 || len(forecastHorizons) > 0
+	for _, p := range providers {
+		if p == "openweather" {
+			needsOpenweatherKey = true
+		}
+	}
+	if needsOpenweatherKey && *openweatherAPIKey == "" && *openweatherAPIKeyFile == "" {
+		log.Printf("One of --openweather-api-key or --openweather-api-key-file is required" +
+			" when using the openweather provider, --group-radius-degrees, or --forecast-horizons")
 		os.Exit(2)
 	}
-	k, err := readKeyOrFile(*openweatherAPIKey, *openweatherAPIKeyFile)
+	var k string
+	if *openweatherAPIKey != "" || *openweatherAPIKeyFile != "" {
+		var err error
+		k, err = readKeyOrFile(*openweatherAPIKey, *openweatherAPIKeyFile)
+		if err != nil {
+			log.Fatalf("Error reading openweather key: %v", err)
+		}
+	}
+
+	cache, err := collector.LoadDiskCache(*cacheFile)
 	if err != nil {
-		log.Fatalf("Error reading openweather key: %v", err)
+		log.Fatalf("Error loading weather cache: %v", err)
+	}
+
+	// Shared across every location/provider so --daily-openweather-call-limit
+	// budgets the whole process, not each location independently.
+	sharedLimiter := collector.NewSharedLimiter(*dailyOWLimit, cache)
+
+	var groupCollector *collector.GroupCollector
+	if *groupRadiusDegrees > 0 {
+		points := make([]collector.Point, len(locations))
+		for i, l := range locations {
+			points[i] = collector.Point{Lat: l.lat, Lng: l.lng}
+		}
+		groupCollector = collector.NewGroupCollector(k, sharedLimiter, points, *groupRadiusDegrees, collectionTTL)
 	}
 
 	for _, l := range locations {
 		ls := l.String()
-		collectors[ls] = &ttlCollector{
-			collector: collector.NewCollector(k, *dailyOWLimit, l.lat, l.lng),
+		for _, providerName := range providers {
+			provider, err := collector.NewProvider(providerName, k)
+			if err != nil {
+				log.Fatalf("Error configuring provider %q: %v", providerName, err)
+			}
+			key := ls + "/" + providerName
+			raw := collector.NewCollector(provider, k, sharedLimiter, l.lat, l.lng, cache, key)
+
+			var cc conditionsCollector = raw
+			if groupCollector != nil && providerName == "openweather" {
+				cc = &groupMember{group: groupCollector, point: collector.Point{Lat: l.lat, Lng: l.lng}}
+			}
+			tc := &ttlCollector{collector: cc, location: ls, provider: providerName}
+			if entry, ok := cache.Get(key); ok {
+				tc.conditions, tc.timestamp = entry.Conditions, entry.Timestamp
+			}
+			collectors[key] = tc
+			export(collectors[key], ls, providerName)
+		}
+
+		// The forecast subsystem always calls the OpenWeather One Call API
+		// directly (see Collector.getForecast), regardless of which
+		// WeatherProvider(s) were selected for current conditions, so it's
+		// exported once per location rather than once per providerName, and
+		// always labeled "openweather" rather than the iterated provider.
+		if len(forecastHorizons) > 0 {
+			forecastProvider, err := collector.NewProvider("openweather", k)
+			if err != nil {
+				log.Fatalf("Error configuring forecast provider: %v", err)
+			}
+			forecastCollector := collector.NewCollector(forecastProvider, k, sharedLimiter, l.lat, l.lng, cache, ls+"/forecast")
+			exportForecast(&ttlForecastCollector{collector: forecastCollector}, ls, "openweather")
 		}
-		export(collectors[ls], ls)
 	}
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "openweather",
+			Name:      "daily_quota_remaining",
+			Help:      "Remaining calls in today's --daily-openweather-call-limit budget, shared across all locations/providers",
+		}, func() float64 { return float64(sharedLimiter.Remaining()) }))
+
 	http.Handle("/metrics", promhttp.Handler())
 	log.Fatal(http.ListenAndServe(*listen, nil))
 }